@@ -1,13 +1,18 @@
 // Package cache implements simple GOB encoded key value cache.
 //
 // Cache is intended for use with unimportant data: load and save errors are
-// ignored. Also, expired entries are dropped/ checked only at cache load time,
-// so it is not suitable for long lived processes.
+// ignored. Expiration is a per-entry absolute deadline set by the caller at
+// Set time (a distant deadline such as math.MaxInt64 effectively means
+// "never expires"; a zero deadline has already passed for any positive
+// now), checked on every Get so long-lived processes don't serve stale entries
+// between loads. Persistence is pluggable via Store, so a Cache can be
+// backed by a file, or not persisted at all.
 package cache
 
 import (
 	"encoding/gob"
 	"io"
+	"sync"
 )
 
 type item struct {
@@ -15,43 +20,84 @@ type item struct {
 	Value   interface{}
 }
 
-// Cache implements simple key/value cache based on file with GOB encoding and value lifetime.
+// Cache implements simple key/value cache with per-entry expiration. Safe
+// for concurrent use.
 type Cache struct {
-	m map[interface{}]item
+	mu sync.RWMutex
+	m  map[interface{}]item
 }
 
-// Load loads cache from given reader, ignoring entries whose expire time is before cutoff.
-// Any errors during loading are ignored and empty cache is returned in that case.
-func Load(r io.Reader, cutoff int64) *Cache {
-	c := &Cache{make(map[interface{}]item)}
+// New returns an empty Cache.
+func New() *Cache {
+	return &Cache{m: make(map[interface{}]item)}
+}
 
-	if err := gob.NewDecoder(r).Decode(&c.m); err != nil {
-		return c
-	}
+// Set sets value for the given key, with an absolute expiration timestamp.
+func (c *Cache) Set(key, value interface{}, expires int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[key] = item{expires, value}
+}
 
-	// purge expired items
-	for k, v := range c.m {
-		if v.Expires < cutoff {
-			delete(c.m, k)
-		}
+// Get fetches a value associated with the given key, treating it as absent
+// if it expired before now. Expired entries are dropped as they're found.
+func (c *Cache) Get(key interface{}, now int64) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	it, ok := c.m[key]
+	if !ok {
+		return nil, false
+	}
+	if it.Expires < now {
+		delete(c.m, key)
+		return nil, false
 	}
-	return c
+	return it.Value, true
 }
 
-// Save saves cache to given writer, ignoring any errors.
-func (c *Cache) Save(w io.Writer) error {
-	return gob.NewEncoder(w).Encode(c.m)
+// Store (de)serializes a Cache's entries to/from a stream.
+type Store interface {
+	// Load merges entries decoded from r into the Cache.
+	Load(r io.Reader) error
+	// Save encodes the Cache's entries to w.
+	Save(w io.Writer) error
 }
 
-// Set sets value for the given key.
-func (c *Cache) Set(key, value interface{}, expires int64) {
-	c.m[key] = item{expires, value}
+// GobStore is a Store backed by GOB encoding, suitable for saving a Cache to
+// a file.
+type GobStore struct {
+	c *Cache
 }
 
-// Get fetches a value associated with the given key. Expiration time is not checked.
-func (c *Cache) Get(key interface{}) (interface{}, bool) {
-	if item, ok := c.m[key]; ok {
-		return item.Value, true
-	}
-	return nil, false
+// NewGobStore returns a GobStore persisting the given Cache.
+func NewGobStore(c *Cache) *GobStore {
+	return &GobStore{c}
+}
+
+// Load decodes entries from r and merges them into the underlying Cache:
+// decoded keys overwrite any existing entry, but pre-existing keys absent
+// from r are left untouched. Any malformed input is reported; the caller is
+// expected to ignore it and carry on with an empty cache, per package docs.
+func (s *GobStore) Load(r io.Reader) error {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	return gob.NewDecoder(r).Decode(&s.c.m)
+}
+
+// Save encodes the underlying Cache's entries to w.
+func (s *GobStore) Save(w io.Writer) error {
+	s.c.mu.RLock()
+	defer s.c.mu.RUnlock()
+	return gob.NewEncoder(w).Encode(s.c.m)
 }
+
+// NoopStore is a Store that keeps everything in memory: Load is a no-op
+// (the Cache starts empty) and Save discards its contents. Useful for
+// daemon mode or for tests that shouldn't touch disk.
+type NoopStore struct{}
+
+// Load does nothing.
+func (NoopStore) Load(io.Reader) error { return nil }
+
+// Save does nothing.
+func (NoopStore) Save(io.Writer) error { return nil }
@@ -2,19 +2,20 @@ package cache
 
 import (
 	"bytes"
+	"sync"
 	"testing"
 )
 
 func TestCache(t *testing.T) {
-	c := Load(bytes.NewBuffer(nil), 1000)
+	c := New()
 	if len(c.m) != 0 {
 		t.Errorf("cache is not empty, has %v elems", len(c.m))
 	}
 
+	store := NewGobStore(c)
 	b := bytes.NewBuffer([]byte("garbage"))
-	c = Load(b, 1000)
-	if len(c.m) != 0 {
-		t.Errorf("cache is not empty, has %v elems", len(c.m))
+	if err := store.Load(b); err == nil {
+		t.Errorf("Load() of garbage must fail, but it doesn't")
 	}
 
 	// set some values, check they are present.
@@ -23,7 +24,7 @@ func TestCache(t *testing.T) {
 		c.Set(i, 2*i, int64(i))
 	}
 	for i := 0; i < nItems; i++ {
-		if v, ok := c.Get(i); ok {
+		if v, ok := c.Get(i, 0); ok {
 			if got := v.(int); got != 2*i {
 				t.Errorf("value for key %v is %v, want %v", i, got, 2*i)
 			}
@@ -32,17 +33,16 @@ func TestCache(t *testing.T) {
 		}
 	}
 	b.Reset()
-	c.Save(b)
+	if err := store.Save(b); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
 
-	// half of the items should be dropped
-	c = Load(b, int64(nItems/2))
-	for i := 0; i < nItems/2; i++ {
-		if _, ok := c.Get(i); ok {
-			t.Errorf("key %v should be absent", i)
-		}
+	c2 := New()
+	if err := NewGobStore(c2).Load(b); err != nil {
+		t.Fatalf("Load() error = %v", err)
 	}
-	for i := nItems/2 + 1; i < nItems; i++ {
-		if v, ok := c.Get(i); ok {
+	for i := 0; i < nItems; i++ {
+		if v, ok := c2.Get(i, 0); ok {
 			if got := v.(int); got != 2*i {
 				t.Errorf("value for key %v is %v, want %v", i, got, 2*i)
 			}
@@ -51,3 +51,43 @@ func TestCache(t *testing.T) {
 		}
 	}
 }
+
+func TestCache_Get_ExpiresOnGet(t *testing.T) {
+	c := New()
+	c.Set("k", "v", 100)
+
+	if _, ok := c.Get("k", 50); !ok {
+		t.Errorf("key should still be present before it expires")
+	}
+	if _, ok := c.Get("k", 150); ok {
+		t.Errorf("key should be absent once its Expires has passed, even though it was never reloaded")
+	}
+}
+
+func TestNoopStore(t *testing.T) {
+	var s NoopStore
+	if err := s.Load(bytes.NewBufferString("anything")); err != nil {
+		t.Errorf("Load() error = %v, want nil", err)
+	}
+	var b bytes.Buffer
+	if err := s.Save(&b); err != nil {
+		t.Errorf("Save() error = %v, want nil", err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("Save() wrote %d bytes, want 0", b.Len())
+	}
+}
+
+func TestCache_ConcurrentAccess(t *testing.T) {
+	c := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.Set(i, i, 1000)
+			c.Get(i, 0)
+		}(i)
+	}
+	wg.Wait()
+}
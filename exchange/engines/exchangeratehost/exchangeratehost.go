@@ -0,0 +1,238 @@
+// Package exchangeratehost implements exchange.Exchanger on top of the
+// exchangerate.host API.
+package exchangeratehost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultBase = "https://api.exchangerate.host"
+
+// Name is the engine name used in --provider / FIATCONV_PROVIDER and cache keys.
+const Name = "exchangerate.host"
+
+const dateLayout = "2006-01-02"
+
+// Engine is an exchange.Exchanger backed by exchangerate.host.
+type Engine struct {
+	client *http.Client
+	base   string
+}
+
+// Option configures Engine.
+type Option func(*Engine)
+
+// WithClient returns Option telling Engine to use given HTTP client.
+func WithClient(client *http.Client) Option {
+	return func(e *Engine) { e.client = client }
+}
+
+// WithBase returns Option telling Engine to use given HTTP base.
+func WithBase(base string) Option {
+	return func(e *Engine) { e.base = base }
+}
+
+func makeURL(base, urlPath string, query url.Values) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	u.RawQuery = query.Encode()
+	u.Path = urlPath
+	return u.String(), nil
+}
+
+func decodeRate(r io.Reader, from, to string) (float64, error) {
+	var response struct {
+		Success bool
+		Base    string
+		Rates   map[string]float64
+	}
+	if err := json.NewDecoder(r).Decode(&response); err != nil {
+		return 0, err
+	}
+	if !response.Success {
+		return 0, errors.New("request was not successful")
+	}
+	if response.Base != from {
+		return 0, fmt.Errorf("unexpected base in response: %s", response.Base)
+	}
+	if v, ok := response.Rates[to]; ok {
+		return v, nil
+	}
+	return 0, errors.New("target code not found in response")
+}
+
+func decodeRates(r io.Reader, from string, to []string) (map[string]float64, error) {
+	var response struct {
+		Success bool
+		Base    string
+		Rates   map[string]float64
+	}
+	if err := json.NewDecoder(r).Decode(&response); err != nil {
+		return nil, err
+	}
+	if !response.Success {
+		return nil, errors.New("request was not successful")
+	}
+	if response.Base != from {
+		return nil, fmt.Errorf("unexpected base in response: %s", response.Base)
+	}
+	rates := make(map[string]float64, len(to))
+	for _, t := range to {
+		v, ok := response.Rates[t]
+		if !ok {
+			return nil, fmt.Errorf("target code not found in response: %s", t)
+		}
+		rates[t] = v
+	}
+	return rates, nil
+}
+
+func decodeTimeSeries(r io.Reader, from, to string) (map[time.Time]float64, error) {
+	var response struct {
+		Success bool
+		Base    string
+		Rates   map[string]map[string]float64
+	}
+	if err := json.NewDecoder(r).Decode(&response); err != nil {
+		return nil, err
+	}
+	if !response.Success {
+		return nil, errors.New("request was not successful")
+	}
+	if response.Base != from {
+		return nil, fmt.Errorf("unexpected base in response: %s", response.Base)
+	}
+	series := make(map[time.Time]float64, len(response.Rates))
+	for day, rates := range response.Rates {
+		date, err := time.Parse(dateLayout, day)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected date in response: %s", day)
+		}
+		v, ok := rates[to]
+		if !ok {
+			return nil, fmt.Errorf("target code not found in response for %s", day)
+		}
+		series[date] = v
+	}
+	return series, nil
+}
+
+// New returns new exchangerate.host engine.
+func New(opts ...Option) *Engine {
+	e := Engine{http.DefaultClient, defaultBase}
+	for _, option := range opts {
+		option(&e)
+	}
+	return &e
+}
+
+// Name returns the engine name.
+func (e *Engine) Name() string { return Name }
+
+func (e *Engine) get(ctx context.Context, urlPath string, query url.Values) (io.ReadCloser, error) {
+	u, err := makeURL(e.base, urlPath, query)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP status code: %v", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// Convert returns exchange rate using "from" currency as base and "to" as target.
+func (e *Engine) Convert(from, to string) (float64, error) {
+	return e.ConvertContext(context.Background(), from, to)
+}
+
+// ConvertContext is like Convert, but honors ctx cancellation/deadline for the duration of the call.
+func (e *Engine) ConvertContext(ctx context.Context, from, to string) (float64, error) {
+	q := make(url.Values)
+	q.Set("base", from)
+	q.Set("symbols", to)
+	body, err := e.get(ctx, "/latest", q)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	return decodeRate(body, from, to)
+}
+
+// ConvertMany returns exchange rates using "from" currency as base and every
+// currency in "to" as a target, in a single HTTP call.
+func (e *Engine) ConvertMany(from string, to []string) (map[string]float64, error) {
+	return e.ConvertManyContext(context.Background(), from, to)
+}
+
+// ConvertManyContext is like ConvertMany, but honors ctx cancellation/deadline for the duration of the call.
+func (e *Engine) ConvertManyContext(ctx context.Context, from string, to []string) (map[string]float64, error) {
+	q := make(url.Values)
+	q.Set("base", from)
+	q.Set("symbols", strings.Join(to, ","))
+	body, err := e.get(ctx, "/latest", q)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return decodeRates(body, from, to)
+}
+
+// ConvertAt returns the exchange rate as it was on the given date.
+func (e *Engine) ConvertAt(date time.Time, from, to string) (float64, error) {
+	return e.ConvertAtContext(context.Background(), date, from, to)
+}
+
+// ConvertAtContext is like ConvertAt, but honors ctx cancellation/deadline for the duration of the call.
+func (e *Engine) ConvertAtContext(ctx context.Context, date time.Time, from, to string) (float64, error) {
+	q := make(url.Values)
+	q.Set("base", from)
+	q.Set("symbols", to)
+	body, err := e.get(ctx, "/"+date.Format(dateLayout), q)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	return decodeRate(body, from, to)
+}
+
+// TimeSeries returns the exchange rate for every day in [start, end].
+func (e *Engine) TimeSeries(start, end time.Time, from, to string) (map[time.Time]float64, error) {
+	return e.TimeSeriesContext(context.Background(), start, end, from, to)
+}
+
+// TimeSeriesContext is like TimeSeries, but honors ctx cancellation/deadline for the duration of the call.
+func (e *Engine) TimeSeriesContext(ctx context.Context, start, end time.Time, from, to string) (map[time.Time]float64, error) {
+	q := make(url.Values)
+	q.Set("base", from)
+	q.Set("symbols", to)
+	q.Set("start_date", start.Format(dateLayout))
+	q.Set("end_date", end.Format(dateLayout))
+	body, err := e.get(ctx, "/timeseries", q)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return decodeTimeSeries(body, from, to)
+}
@@ -0,0 +1,410 @@
+package exchangeratesapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	customClient := &http.Client{Timeout: 10 * time.Second}
+	customBase := "https://example.com"
+	tests := []struct {
+		name string
+		opts []Option
+		want *Engine
+	}{
+		{"default", nil, &Engine{http.DefaultClient, defaultBase}},
+		{
+			"base",
+			[]Option{WithBase(customBase)},
+			&Engine{http.DefaultClient, customBase},
+		},
+		{
+			"client",
+			[]Option{WithClient(customClient)},
+			&Engine{customClient, defaultBase},
+		},
+		{
+			"base and client",
+			[]Option{WithBase(customBase), WithClient(customClient)},
+			&Engine{customClient, customBase},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.opts...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("New() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_makeURL(t *testing.T) {
+	query := func(from, to string) url.Values {
+		q := make(url.Values)
+		q.Set("base", from)
+		q.Set("symbols", to)
+		return q
+	}
+	type args struct {
+		base    string
+		urlPath string
+		query   url.Values
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{"invalid base", args{":", "/latest", query("USD", "AUD")}, "", true},
+		{"valid", args{"https://example.com", "/latest", query("USD", "AUD")}, "https://example.com/latest?base=USD&symbols=AUD", false},
+		{"valid with trailing slash", args{"https://example.com/", "/latest", query("USD", "GBP")}, "https://example.com/latest?base=USD&symbols=GBP", false},
+		{"valid with date path", args{"https://example.com", "/2020-11-20", query("USD", "GBP")}, "https://example.com/2020-11-20?base=USD&symbols=GBP", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := makeURL(tt.args.base, tt.args.urlPath, tt.args.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("makeURL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("makeURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_decodeRate(t *testing.T) {
+	type args struct {
+		r    io.Reader
+		from string
+		to   string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    float64
+		wantErr bool
+	}{
+		{"invalid JSON", args{strings.NewReader(""), "", ""}, 0, true},
+		{
+			"valid response",
+			args{
+				strings.NewReader(`{"rates":{"AUD":1.5},"base":"USD","date":"2020-11-20"}`),
+				"USD",
+				"AUD",
+			},
+			1.5,
+			false,
+		},
+		{
+			"invalid base",
+			args{
+				strings.NewReader(`{"rates":{"AUD":1.5},"base":"XYZ","date":"2020-11-20"}`),
+				"USD",
+				"AUD",
+			},
+			0,
+			true,
+		},
+		{
+			"missing target",
+			args{
+				strings.NewReader(`{"rates":{"XYZ":1.5},"base":"USD","date":"2020-11-20"}`),
+				"USD",
+				"AUD",
+			},
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeRate(tt.args.r, tt.args.from, tt.args.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeRate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("decodeRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_decodeTimeSeries(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse(dateLayout, s)
+		if err != nil {
+			t.Fatalf("invalid test date %s: %v", s, err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		from    string
+		to      string
+		want    map[time.Time]float64
+		wantErr bool
+	}{
+		{"invalid JSON", "", "USD", "AUD", nil, true},
+		{
+			"valid response",
+			`{"rates":{"2020-11-20":{"AUD":1.5},"2020-11-21":{"AUD":1.6}},"base":"USD"}`,
+			"USD",
+			"AUD",
+			map[time.Time]float64{day("2020-11-20"): 1.5, day("2020-11-21"): 1.6},
+			false,
+		},
+		{
+			"invalid base",
+			`{"rates":{"2020-11-20":{"AUD":1.5}},"base":"XYZ"}`,
+			"USD",
+			"AUD",
+			nil,
+			true,
+		},
+		{
+			"missing target",
+			`{"rates":{"2020-11-20":{"XYZ":1.5}},"base":"USD"}`,
+			"USD",
+			"AUD",
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeTimeSeries(strings.NewReader(tt.body), tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeTimeSeries() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeTimeSeries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_decodeRates(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		from    string
+		to      []string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{"invalid JSON", "", "USD", []string{"AUD"}, nil, true},
+		{
+			"valid response",
+			`{"rates":{"AUD":1.5,"GBP":0.8},"base":"USD","date":"2020-11-20"}`,
+			"USD",
+			[]string{"AUD", "GBP"},
+			map[string]float64{"AUD": 1.5, "GBP": 0.8},
+			false,
+		},
+		{
+			"invalid base",
+			`{"rates":{"AUD":1.5},"base":"XYZ","date":"2020-11-20"}`,
+			"USD",
+			[]string{"AUD"},
+			nil,
+			true,
+		},
+		{
+			"missing target",
+			`{"rates":{"AUD":1.5},"base":"USD","date":"2020-11-20"}`,
+			"USD",
+			[]string{"AUD", "GBP"},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeRates(strings.NewReader(tt.body), tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeRates() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeRates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_Convert(t *testing.T) {
+	goodSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"rates":{"AUD":2},"base":"USD","date":"2020-11-20"}`))
+	}))
+	defer goodSrv.Close()
+
+	// same as goodSrv, but gives 404
+	notFoundSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"rates":{"AUD":2},"base":"USD","date":"2020-11-20"}`))
+	}))
+	defer notFoundSrv.Close()
+
+	type args struct {
+		from, to string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		baseURL string
+		want    float64
+		wantErr bool
+	}{
+		{
+			"success",
+			args{"USD", "AUD"},
+			goodSrv.URL,
+			2,
+			false,
+		},
+		{
+			"wrong source currency",
+			args{"XYZ", "AUD"},
+			goodSrv.URL,
+			0,
+			true,
+		},
+		{
+			"missing target currency",
+			args{"USD", "XYZ"},
+			goodSrv.URL,
+			0,
+			true,
+		},
+		{
+			"bad http status code",
+			args{"USD", "AUD"},
+			notFoundSrv.URL,
+			0,
+			true,
+		},
+		{
+			"invalid base URL",
+			args{"USD", "AUD"},
+			":",
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(WithBase(tt.baseURL))
+			got, err := e.Convert(tt.args.from, tt.args.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Convert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Convert() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("API error reported", func(t *testing.T) {
+		const errMsg = "Feeling bad today"
+		badSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "%s"}`, errMsg)
+		}))
+		defer badSrv.Close()
+
+		e := New(WithBase(badSrv.URL))
+		_, err := e.Convert("USD", "AUD")
+		if err == nil {
+			t.Errorf("Convert() must fail, but it doesn't")
+		} else if err.Error() != errMsg {
+			t.Errorf("Convert() error = %v, want %v", err, errMsg)
+		}
+	})
+}
+
+func TestEngine_ConvertAt(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2020-11-20" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"rates":{"AUD":2},"base":"USD","date":"2020-11-20"}`))
+	}))
+	defer srv.Close()
+
+	e := New(WithBase(srv.URL))
+	got, err := e.ConvertAt(time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), "USD", "AUD")
+	if err != nil {
+		t.Fatalf("ConvertAt() error = %v", err)
+	}
+	if got != 2 {
+		t.Errorf("ConvertAt() = %v, want 2", got)
+	}
+}
+
+func TestEngine_ConvertMany(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Query().Get("symbols"), "AUD,GBP"; got != want {
+			t.Errorf("unexpected symbols: %s, want %s", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"rates":{"AUD":2,"GBP":0.8},"base":"USD","date":"2020-11-20"}`))
+	}))
+	defer srv.Close()
+
+	e := New(WithBase(srv.URL))
+	got, err := e.ConvertMany("USD", []string{"AUD", "GBP"})
+	if err != nil {
+		t.Fatalf("ConvertMany() error = %v", err)
+	}
+	want := map[string]float64{"AUD": 2, "GBP": 0.8}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConvertMany() = %v, want %v", got, want)
+	}
+}
+
+func TestEngine_TimeSeries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/history" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"rates":{"2020-11-20":{"AUD":2},"2020-11-21":{"AUD":2.1}},"base":"USD"}`))
+	}))
+	defer srv.Close()
+
+	e := New(WithBase(srv.URL))
+	got, err := e.TimeSeries(
+		time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC),
+		"USD", "AUD",
+	)
+	if err != nil {
+		t.Fatalf("TimeSeries() error = %v", err)
+	}
+	want := map[time.Time]float64{
+		time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC): 2,
+		time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC): 2.1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TimeSeries() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+// Package fixed implements exchange.Exchanger with a fixed, in-memory rate
+// table. It makes no network calls and is meant for offline testing, or as
+// a caller-wired fallback for deployments willing to pin a rate table of
+// their own rather than trust every remote provider being down. newExchanger
+// doesn't include it in the default chain, since fiatconv ships no rate
+// table of its own to fall back to.
+package fixed
+
+import (
+	"context"
+	"fmt"
+)
+
+// Name is the engine name used in --provider / FIATCONV_PROVIDER and cache keys.
+const Name = "fixed"
+
+// Engine is an exchange.Exchanger backed by a fixed set of rates, all
+// expressed against a single base currency.
+type Engine struct {
+	base  string
+	rates map[string]float64
+}
+
+// New returns new fixed-rates engine. Rates are given against base, eg. for
+// base "USD" a rates map of {"EUR": 0.9} means 1 USD = 0.9 EUR. base itself
+// is implicitly in the map with a rate of 1.
+func New(base string, rates map[string]float64) *Engine {
+	return &Engine{base, rates}
+}
+
+// Name returns the engine name.
+func (e *Engine) Name() string { return Name }
+
+func (e *Engine) rate(code string) (float64, bool) {
+	if code == e.base {
+		return 1, true
+	}
+	v, ok := e.rates[code]
+	return v, ok
+}
+
+// Convert returns exchange rate using "from" currency as base and "to" as target.
+func (e *Engine) Convert(from, to string) (float64, error) {
+	return e.ConvertContext(context.Background(), from, to)
+}
+
+// ConvertContext is like Convert, but returns ctx.Err() if ctx is already done.
+// Since no I/O is involved, there is nothing else to cancel.
+func (e *Engine) ConvertContext(ctx context.Context, from, to string) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	fromRate, ok := e.rate(from)
+	if !ok {
+		return 0, fmt.Errorf("no fixed rate for %s", from)
+	}
+	toRate, ok := e.rate(to)
+	if !ok {
+		return 0, fmt.Errorf("no fixed rate for %s", to)
+	}
+	return toRate / fromRate, nil
+}
+
+// ConvertMany returns exchange rates using "from" currency as base and every
+// currency in "to" as a target.
+func (e *Engine) ConvertMany(from string, to []string) (map[string]float64, error) {
+	return e.ConvertManyContext(context.Background(), from, to)
+}
+
+// ConvertManyContext is like ConvertMany, but returns ctx.Err() if ctx is already done.
+func (e *Engine) ConvertManyContext(ctx context.Context, from string, to []string) (map[string]float64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	fromRate, ok := e.rate(from)
+	if !ok {
+		return nil, fmt.Errorf("no fixed rate for %s", from)
+	}
+	rates := make(map[string]float64, len(to))
+	for _, t := range to {
+		toRate, ok := e.rate(t)
+		if !ok {
+			return nil, fmt.Errorf("no fixed rate for %s", t)
+		}
+		rates[t] = toRate / fromRate
+	}
+	return rates, nil
+}
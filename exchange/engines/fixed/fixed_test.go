@@ -0,0 +1,60 @@
+package fixed
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEngine_Convert(t *testing.T) {
+	e := New("USD", map[string]float64{"EUR": 0.9, "GBP": 0.8})
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     float64
+		wantErr  bool
+	}{
+		{"base to rate", "USD", "EUR", 0.9, false},
+		{"rate to base", "EUR", "USD", 1 / 0.9, false},
+		{"rate to rate", "EUR", "GBP", 0.888888888888889, false},
+		{"same currency", "USD", "USD", 1, false},
+		{"unknown from", "XYZ", "USD", 0, true},
+		{"unknown to", "USD", "XYZ", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.Convert(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Convert() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Convert() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_ConvertMany(t *testing.T) {
+	e := New("USD", map[string]float64{"EUR": 0.9, "GBP": 0.8})
+
+	got, err := e.ConvertMany("USD", []string{"EUR", "GBP"})
+	if err != nil {
+		t.Fatalf("ConvertMany() error = %v", err)
+	}
+	want := map[string]float64{"EUR": 0.9, "GBP": 0.8}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConvertMany() = %v, want %v", got, want)
+	}
+
+	if _, err := e.ConvertMany("USD", []string{"EUR", "XYZ"}); err == nil {
+		t.Errorf("ConvertMany() must fail for an unknown target, but it doesn't")
+	}
+}
+
+func TestEngine_Name(t *testing.T) {
+	e := New("USD", nil)
+	if got := e.Name(); got != Name {
+		t.Errorf("Name() = %v, want %v", got, Name)
+	}
+}
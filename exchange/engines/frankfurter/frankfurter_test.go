@@ -0,0 +1,117 @@
+package frankfurter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEngine_Convert(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/latest" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"amount":1,"base":"USD","date":"2020-11-20","rates":{"AUD":2}}`))
+	}))
+	defer srv.Close()
+
+	e := New(WithBase(srv.URL))
+	got, err := e.Convert("USD", "AUD")
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if got != 2 {
+		t.Errorf("Convert() = %v, want 2", got)
+	}
+}
+
+func TestEngine_ConvertMany(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Query().Get("to"), "AUD,GBP"; got != want {
+			t.Errorf("unexpected to: %s, want %s", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"amount":1,"base":"USD","date":"2020-11-20","rates":{"AUD":2,"GBP":0.8}}`))
+	}))
+	defer srv.Close()
+
+	e := New(WithBase(srv.URL))
+	got, err := e.ConvertMany("USD", []string{"AUD", "GBP"})
+	if err != nil {
+		t.Fatalf("ConvertMany() error = %v", err)
+	}
+	want := map[string]float64{"AUD": 2, "GBP": 0.8}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConvertMany() = %v, want %v", got, want)
+	}
+}
+
+func TestEngine_ConvertAt(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2020-11-20" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"amount":1,"base":"USD","date":"2020-11-20","rates":{"AUD":2}}`))
+	}))
+	defer srv.Close()
+
+	e := New(WithBase(srv.URL))
+	got, err := e.ConvertAt(time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), "USD", "AUD")
+	if err != nil {
+		t.Fatalf("ConvertAt() error = %v", err)
+	}
+	if got != 2 {
+		t.Errorf("ConvertAt() = %v, want 2", got)
+	}
+}
+
+func TestEngine_TimeSeries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2020-11-20..2020-11-21" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"amount":1,"base":"USD","start_date":"2020-11-20","end_date":"2020-11-21","rates":{"2020-11-20":{"AUD":2},"2020-11-21":{"AUD":2.1}}}`))
+	}))
+	defer srv.Close()
+
+	e := New(WithBase(srv.URL))
+	got, err := e.TimeSeries(
+		time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC),
+		"USD", "AUD",
+	)
+	if err != nil {
+		t.Fatalf("TimeSeries() error = %v", err)
+	}
+	want := map[time.Time]float64{
+		time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC): 2,
+		time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC): 2.1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TimeSeries() = %v, want %v", got, want)
+	}
+}
+
+func TestEngine_Convert_APIError(t *testing.T) {
+	const errMsg = "Feeling bad today"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"` + errMsg + `"}`))
+	}))
+	defer srv.Close()
+
+	e := New(WithBase(srv.URL))
+	_, err := e.Convert("USD", "AUD")
+	if err == nil {
+		t.Fatalf("Convert() must fail, but it doesn't")
+	}
+	if err.Error() != errMsg {
+		t.Errorf("Convert() error = %v, want %v", err, errMsg)
+	}
+}
@@ -1,96 +1,318 @@
-// Package exchange implements (part of) currency converter API provided by exchangeratesapi.io.
+// Package exchange defines the Exchanger interface used to fetch fiat
+// currency conversion rates, and Multi, which combines several Exchangers
+// into a single fallback chain.
+//
+// Concrete implementations live under exchange/engines.
 package exchange
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
+	"sort"
+	"time"
 )
 
-const defaultBase = "https://api.exchangeratesapi.io"
+// Exchanger fetches an exchange rate from a "from" currency to a "to"
+// currency and identifies itself by name.
+type Exchanger interface {
+	// Convert returns exchange rate using "from" currency as base and "to" as target.
+	Convert(from, to string) (float64, error)
+	// ConvertContext is like Convert, but honors ctx cancellation/deadline for
+	// the duration of the call.
+	ConvertContext(ctx context.Context, from, to string) (float64, error)
+	// Name identifies the engine, eg. for use in cache keys or --provider selection.
+	Name() string
+}
+
+// HistoricalExchanger is implemented by engines that can also serve past
+// rates, in addition to the live one. Engines that only track the current
+// rate (eg. fixed) don't implement it.
+type HistoricalExchanger interface {
+	// ConvertAt returns the exchange rate as it was on the given date.
+	ConvertAt(date time.Time, from, to string) (float64, error)
+	// ConvertAtContext is like ConvertAt, but honors ctx cancellation/deadline.
+	ConvertAtContext(ctx context.Context, date time.Time, from, to string) (float64, error)
+	// TimeSeries returns the exchange rate for every day in [start, end].
+	TimeSeries(start, end time.Time, from, to string) (map[time.Time]float64, error)
+	// TimeSeriesContext is like TimeSeries, but honors ctx cancellation/deadline.
+	TimeSeriesContext(ctx context.Context, start, end time.Time, from, to string) (map[time.Time]float64, error)
+}
 
-// API is exchange rate client.
-type API struct {
-	client *http.Client
-	base   string
+// BatchExchanger is implemented by engines that can fetch rates for several
+// target currencies in a single call, cheaper than one Convert per target.
+type BatchExchanger interface {
+	// ConvertMany returns exchange rates using "from" currency as base and
+	// every currency in "to" as a target.
+	ConvertMany(from string, to []string) (map[string]float64, error)
+	// ConvertManyContext is like ConvertMany, but honors ctx cancellation/deadline.
+	ConvertManyContext(ctx context.Context, from string, to []string) (map[string]float64, error)
 }
 
-// Option configure API.
-type Option func(*API)
+// Policy controls how Multi combines the results of its engines.
+type Policy int
+
+const (
+	// FirstSuccess returns the result of the first engine that succeeds, in order.
+	FirstSuccess Policy = iota
+	// QuorumMedian queries every engine and returns the median of the
+	// successful results, provided a majority of engines succeeded.
+	QuorumMedian
+	// ExplicitByName queries only the engine matching the configured name.
+	ExplicitByName
+)
 
-// WithClient returns Option telling API to use given HTTP client.
-func WithClient(client *http.Client) Option {
-	return func(a *API) { a.client = client }
+// Multi combines several Exchangers into one, according to Policy.
+type Multi struct {
+	engines []Exchanger
+	policy  Policy
+	name    string // used by ExplicitByName
 }
 
-// WithBase returns Option telling API to use given HTTP base.
-func WithBase(base string) Option {
-	return func(a *API) { a.base = base }
+// Option configures Multi.
+type Option func(*Multi)
+
+// WithPolicy returns Option setting the combination policy. Default is FirstSuccess.
+func WithPolicy(policy Policy) Option {
+	return func(m *Multi) { m.policy = policy }
 }
 
-func makeURL(base, from, to string) (string, error) {
-	u, err := url.Parse(base)
-	if err != nil {
-		return "", err
+// WithExplicitName returns Option selecting ExplicitByName policy for the named engine.
+func WithExplicitName(name string) Option {
+	return func(m *Multi) {
+		m.policy = ExplicitByName
+		m.name = name
 	}
-	q := make(url.Values)
-	q.Set("base", from)
-	q.Set("symbols", to)
-	u.RawQuery = q.Encode()
-	u.Path = "/latest"
-	return u.String(), nil
 }
 
-func decodeRate(r io.Reader, from, to string) (float64, error) {
-	var response struct {
-		Rates map[string]float64
-		Base  string
+// NewMulti returns a Multi querying the given engines, in order, according to the
+// given Options.
+func NewMulti(engines []Exchanger, opts ...Option) *Multi {
+	m := &Multi{engines: engines, policy: FirstSuccess}
+	for _, opt := range opts {
+		opt(m)
 	}
-	if err := json.NewDecoder(r).Decode(&response); err != nil {
-		return 0, err
+	return m
+}
+
+// Name returns a name identifying this particular combination of engines and
+// policy, stable for a given configuration, for use as part of a cache key.
+func (m *Multi) Name() string {
+	if m.policy == ExplicitByName {
+		return m.name
 	}
-	if response.Base != from {
-		return 0, fmt.Errorf("unexpected base in response: %s", response.Base)
+	name := "multi"
+	for _, e := range m.engines {
+		name += ":" + e.Name()
 	}
-	if v, ok := response.Rates[to]; ok {
-		return v, nil
+	return name
+}
+
+// Convert returns exchange rate using "from" currency as base and "to" as
+// target, combining the configured engines according to Policy.
+func (m *Multi) Convert(from, to string) (float64, error) {
+	return m.ConvertContext(context.Background(), from, to)
+}
+
+// ConvertContext is like Convert, but honors ctx cancellation/deadline for
+// the duration of the call, including across fallback attempts.
+func (m *Multi) ConvertContext(ctx context.Context, from, to string) (float64, error) {
+	switch m.policy {
+	case ExplicitByName:
+		for _, e := range m.engines {
+			if e.Name() == m.name {
+				return e.ConvertContext(ctx, from, to)
+			}
+		}
+		return 0, fmt.Errorf("unknown provider: %s", m.name)
+	case QuorumMedian:
+		return m.convertQuorumMedian(ctx, from, to)
+	default:
+		return m.convertFirstSuccess(ctx, from, to)
 	}
-	return 0, errors.New("target code not found in response")
 }
 
-// New returns new exchange rate API client.
-func New(opts ...Option) *API {
-	api := API{http.DefaultClient, defaultBase}
-	for _, option := range opts {
-		option(&api)
+func (m *Multi) convertFirstSuccess(ctx context.Context, from, to string) (float64, error) {
+	if len(m.engines) == 0 {
+		return 0, errors.New("no engines configured")
 	}
-	return &api
+	var errs []error
+	for _, e := range m.engines {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		rate, err := e.ConvertContext(ctx, from, to)
+		if err == nil {
+			return rate, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+	}
+	return 0, fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+}
+
+func (m *Multi) convertQuorumMedian(ctx context.Context, from, to string) (float64, error) {
+	if len(m.engines) == 0 {
+		return 0, errors.New("no engines configured")
+	}
+	var rates []float64
+	for _, e := range m.engines {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if rate, err := e.ConvertContext(ctx, from, to); err == nil {
+			rates = append(rates, rate)
+		}
+	}
+	if len(rates) <= len(m.engines)/2 {
+		return 0, fmt.Errorf("no quorum: only %d/%d providers succeeded", len(rates), len(m.engines))
+	}
+	sort.Float64s(rates)
+	mid := len(rates) / 2
+	if len(rates)%2 == 1 {
+		return rates[mid], nil
+	}
+	return (rates[mid-1] + rates[mid]) / 2, nil
 }
 
-// Convert returns exchange rate using "from" currency as base and "to" as target.
-func (api *API) Convert(from, to string) (float64, error) {
-	url, err := makeURL(api.base, from, to)
+// historicalEngines returns the engines eligible to serve a historical
+// query, in order: just the explicitly named one for ExplicitByName, or
+// every configured engine that implements HistoricalExchanger otherwise.
+// Historical queries are always tried first-success, regardless of Policy:
+// there's no sane way to median two different days' time series. Returned
+// as Exchanger, not HistoricalExchanger, so callers still have Name() for
+// error attribution; they type-assert back to HistoricalExchanger to call it.
+func (m *Multi) historicalEngines() ([]Exchanger, error) {
+	if m.policy == ExplicitByName {
+		for _, e := range m.engines {
+			if e.Name() == m.name {
+				if _, ok := e.(HistoricalExchanger); !ok {
+					return nil, fmt.Errorf("provider %s does not support historical queries", m.name)
+				}
+				return []Exchanger{e}, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown provider: %s", m.name)
+	}
+	var hs []Exchanger
+	for _, e := range m.engines {
+		if _, ok := e.(HistoricalExchanger); ok {
+			hs = append(hs, e)
+		}
+	}
+	if len(hs) == 0 {
+		return nil, errors.New("no configured provider supports historical queries")
+	}
+	return hs, nil
+}
+
+// ConvertAt returns the exchange rate as it was on the given date, combining
+// the configured engines first-success.
+func (m *Multi) ConvertAt(date time.Time, from, to string) (float64, error) {
+	return m.ConvertAtContext(context.Background(), date, from, to)
+}
+
+// ConvertAtContext is like ConvertAt, but honors ctx cancellation/deadline
+// for the duration of the call, including across fallback attempts.
+func (m *Multi) ConvertAtContext(ctx context.Context, date time.Time, from, to string) (float64, error) {
+	hs, err := m.historicalEngines()
 	if err != nil {
 		return 0, err
 	}
+	var errs []error
+	for _, e := range hs {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		rate, err := e.(HistoricalExchanger).ConvertAtContext(ctx, date, from, to)
+		if err == nil {
+			return rate, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+	}
+	return 0, fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+}
+
+// batchEngines returns the engines eligible to serve a batch conversion, in
+// order: just the explicitly named one for ExplicitByName, or every
+// configured engine that implements BatchExchanger otherwise. Batch queries
+// are always tried first-success, regardless of Policy, same as historical
+// ones. Returned as Exchanger, not BatchExchanger, so callers still have
+// Name() for error attribution; they type-assert back to BatchExchanger to
+// call it.
+func (m *Multi) batchEngines() ([]Exchanger, error) {
+	if m.policy == ExplicitByName {
+		for _, e := range m.engines {
+			if e.Name() == m.name {
+				if _, ok := e.(BatchExchanger); !ok {
+					return nil, fmt.Errorf("provider %s does not support batch conversion", m.name)
+				}
+				return []Exchanger{e}, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown provider: %s", m.name)
+	}
+	var bs []Exchanger
+	for _, e := range m.engines {
+		if _, ok := e.(BatchExchanger); ok {
+			bs = append(bs, e)
+		}
+	}
+	if len(bs) == 0 {
+		return nil, errors.New("no configured provider supports batch conversion")
+	}
+	return bs, nil
+}
 
-	resp, err := api.client.Get(url)
+// ConvertMany returns exchange rates using "from" currency as base and every
+// currency in "to" as a target, combining the configured engines first-success.
+func (m *Multi) ConvertMany(from string, to []string) (map[string]float64, error) {
+	return m.ConvertManyContext(context.Background(), from, to)
+}
+
+// ConvertManyContext is like ConvertMany, but honors ctx cancellation/deadline
+// for the duration of the call, including across fallback attempts.
+func (m *Multi) ConvertManyContext(ctx context.Context, from string, to []string) (map[string]float64, error) {
+	bs, err := m.batchEngines()
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	var errs []error
+	for _, e := range bs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rates, err := e.(BatchExchanger).ConvertManyContext(ctx, from, to)
+		if err == nil {
+			return rates, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
 	}
-	defer resp.Body.Close()
+	return nil, fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+}
 
-	if resp.StatusCode != http.StatusOK {
-		var r struct {
-			Error string
+// TimeSeries returns the exchange rate for every day in [start, end],
+// combining the configured engines first-success.
+func (m *Multi) TimeSeries(start, end time.Time, from, to string) (map[time.Time]float64, error) {
+	return m.TimeSeriesContext(context.Background(), start, end, from, to)
+}
+
+// TimeSeriesContext is like TimeSeries, but honors ctx cancellation/deadline
+// for the duration of the call, including across fallback attempts.
+func (m *Multi) TimeSeriesContext(ctx context.Context, start, end time.Time, from, to string) (map[time.Time]float64, error) {
+	hs, err := m.historicalEngines()
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, e := range hs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
-		if err = json.NewDecoder(resp.Body).Decode(&r); err == nil && len(r.Error) > 0 {
-			return 0, errors.New(r.Error)
+		series, err := e.(HistoricalExchanger).TimeSeriesContext(ctx, start, end, from, to)
+		if err == nil {
+			return series, nil
 		}
-		return 0, fmt.Errorf("unexpected HTTP status code: %v", resp.StatusCode)
+		errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
 	}
-	return decodeRate(resp.Body, from, to)
+	return nil, fmt.Errorf("all providers failed: %w", errors.Join(errs...))
 }
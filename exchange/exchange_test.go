@@ -1,227 +1,301 @@
 package exchange
 
 import (
-	"fmt"
-	"io"
-	"net/http"
-	"net/http/httptest"
-	"reflect"
+	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
 )
 
-func TestNew(t *testing.T) {
-	customClient := &http.Client{Timeout: 10 * time.Second}
-	customBase := "https://example.com"
-	tests := []struct {
-		name string
-		opts []Option
-		want *API
-	}{
-		{"default", nil, &API{http.DefaultClient, defaultBase}},
-		{
-			"base",
-			[]Option{WithBase(customBase)},
-			&API{http.DefaultClient, customBase},
-		},
-		{
-			"client",
-			[]Option{WithClient(customClient)},
-			&API{customClient, defaultBase},
-		},
-		{
-			"base and client",
-			[]Option{WithBase(customBase), WithClient(customClient)},
-			&API{customClient, customBase},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := New(tt.opts...); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("New() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func Test_makeURL(t *testing.T) {
-	type args struct {
-		base string
-		from string
-		to   string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    string
-		wantErr bool
-	}{
-		{"invalid base", args{":", "USD", "AUD"}, "", true},
-		{"valid", args{"https://example.com", "USD", "AUD"}, "https://example.com/latest?base=USD&symbols=AUD", false},
-		{"valid with trailing slash", args{"https://example.com/", "USD", "GBP"}, "https://example.com/latest?base=USD&symbols=GBP", false},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := makeURL(tt.args.base, tt.args.from, tt.args.to)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("makeURL() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("makeURL() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func Test_decodeRate(t *testing.T) {
-	type args struct {
-		r    io.Reader
-		from string
-		to   string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    float64
-		wantErr bool
-	}{
-		{"invalid JSON", args{strings.NewReader(""), "", ""}, 0, true},
-		{
-			"valid response",
-			args{
-				strings.NewReader(`{"rates":{"AUD":1.5},"base":"USD","date":"2020-11-20"}`),
-				"USD",
-				"AUD",
-			},
-			1.5,
-			false,
-		},
-		{
-			"invalid base",
-			args{
-				strings.NewReader(`{"rates":{"AUD":1.5},"base":"XYZ","date":"2020-11-20"}`),
-				"USD",
-				"AUD",
-			},
-			0,
-			true,
-		},
-		{
-			"missing target",
-			args{
-				strings.NewReader(`{"rates":{"XYZ":1.5},"base":"USD","date":"2020-11-20"}`),
-				"USD",
-				"AUD",
-			},
-			0,
-			true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := decodeRate(tt.args.r, tt.args.from, tt.args.to)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("decodeRate() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("decodeRate() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestAPI_Convert(t *testing.T) {
-	goodSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"rates":{"AUD":2},"base":"USD","date":"2020-11-20"}`))
-	}))
-	defer goodSrv.Close()
-
-	// same as goodSrv, but gives 404
-	notFoundSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"rates":{"AUD":2},"base":"USD","date":"2020-11-20"}`))
-	}))
-	defer notFoundSrv.Close()
-
-	type args struct {
-		from, to string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		baseURL string
-		want    float64
-		wantErr bool
-	}{
-		{
-			"success",
-			args{"USD", "AUD"},
-			goodSrv.URL,
-			2,
-			false,
-		},
-		{
-			"wrong source currency",
-			args{"XYZ", "AUD"},
-			goodSrv.URL,
-			0,
-			true,
-		},
-		{
-			"missing target currency",
-			args{"USD", "XYZ"},
-			goodSrv.URL,
-			0,
-			true,
-		},
-		{
-			"bad http status code",
-			args{"USD", "AUD"},
-			notFoundSrv.URL,
-			0,
-			true,
-		},
-		{
-			"invalid base URL",
-			args{"USD", "AUD"},
-			":",
-			0,
-			true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			api := New(WithBase(tt.baseURL))
-			got, err := api.Convert(tt.args.from, tt.args.to)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Convert() error = %v, wantErr %v", err, tt.wantErr)
-			}
-			if got != tt.want {
-				t.Errorf("Convert() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-
-	t.Run("API error reported", func(t *testing.T) {
-		const errMsg = "Feeling bad today"
-		badSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(w, `{"error": "%s"}`, errMsg)
-		}))
-		defer badSrv.Close()
-
-		api := New(WithBase(badSrv.URL))
-		_, err := api.Convert("USD", "AUD")
-		if err == nil {
-			t.Errorf("Convert() must fail, but it doesn't")
-		} else if err.Error() != errMsg {
-			t.Errorf("Convert() error = %v, want %v", err, errMsg)
-		}
+type stubEngine struct {
+	name string
+	rate float64
+	err  error
+}
+
+func (s stubEngine) Name() string { return s.name }
+func (s stubEngine) Convert(from, to string) (float64, error) {
+	return s.rate, s.err
+}
+func (s stubEngine) ConvertContext(ctx context.Context, from, to string) (float64, error) {
+	return s.rate, s.err
+}
+
+func TestMulti_Convert_FirstSuccess(t *testing.T) {
+	errBoom := errors.New("boom")
+	m := NewMulti([]Exchanger{
+		stubEngine{"a", 0, errBoom},
+		stubEngine{"b", 2, nil},
+		stubEngine{"c", 3, nil},
+	})
+	got, err := m.Convert("USD", "AUD")
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if got != 2 {
+		t.Errorf("Convert() = %v, want 2", got)
+	}
+}
+
+func TestMulti_Convert_FirstSuccess_AllFail(t *testing.T) {
+	m := NewMulti([]Exchanger{
+		stubEngine{"a", 0, errors.New("boom a")},
+		stubEngine{"b", 0, errors.New("boom b")},
+	})
+	if _, err := m.Convert("USD", "AUD"); err == nil {
+		t.Errorf("Convert() must fail, but it doesn't")
+	}
+}
+
+func TestMulti_Convert_QuorumMedian(t *testing.T) {
+	m := NewMulti([]Exchanger{
+		stubEngine{"a", 1, nil},
+		stubEngine{"b", 2, nil},
+		stubEngine{"c", 0, errors.New("boom")},
+	}, WithPolicy(QuorumMedian))
+	got, err := m.Convert("USD", "AUD")
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if got != 1.5 {
+		t.Errorf("Convert() = %v, want 1.5", got)
+	}
+}
+
+func TestMulti_Convert_QuorumMedian_NoQuorum(t *testing.T) {
+	m := NewMulti([]Exchanger{
+		stubEngine{"a", 1, nil},
+		stubEngine{"b", 0, errors.New("boom")},
+		stubEngine{"c", 0, errors.New("boom")},
+	}, WithPolicy(QuorumMedian))
+	if _, err := m.Convert("USD", "AUD"); err == nil {
+		t.Errorf("Convert() must fail, but it doesn't")
+	}
+}
+
+func TestMulti_Convert_ExplicitByName(t *testing.T) {
+	m := NewMulti([]Exchanger{
+		stubEngine{"a", 1, nil},
+		stubEngine{"b", 2, nil},
+	}, WithExplicitName("b"))
+	got, err := m.Convert("USD", "AUD")
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if got != 2 {
+		t.Errorf("Convert() = %v, want 2", got)
+	}
+}
+
+func TestMulti_Convert_ExplicitByName_Unknown(t *testing.T) {
+	m := NewMulti([]Exchanger{
+		stubEngine{"a", 1, nil},
+	}, WithExplicitName("nope"))
+	if _, err := m.Convert("USD", "AUD"); err == nil {
+		t.Errorf("Convert() must fail, but it doesn't")
+	}
+}
+
+func TestMulti_ConvertContext_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m := NewMulti([]Exchanger{stubEngine{"a", 1, nil}})
+	if _, err := m.ConvertContext(ctx, "USD", "AUD"); !errors.Is(err, context.Canceled) {
+		t.Errorf("ConvertContext() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+type stubBatchEngine struct {
+	stubEngine
+	rates map[string]float64
+	err   error
+}
+
+func (s stubBatchEngine) ConvertMany(from string, to []string) (map[string]float64, error) {
+	return s.rates, s.err
+}
+
+func (s stubBatchEngine) ConvertManyContext(ctx context.Context, from string, to []string) (map[string]float64, error) {
+	return s.rates, s.err
+}
+
+func TestMulti_ConvertMany_FirstSuccess(t *testing.T) {
+	errBoom := errors.New("boom")
+	m := NewMulti([]Exchanger{
+		stubBatchEngine{stubEngine: stubEngine{name: "a"}, err: errBoom},
+		stubBatchEngine{stubEngine: stubEngine{name: "b"}, rates: map[string]float64{"EUR": 1, "GBP": 2}},
+	})
+	got, err := m.ConvertMany("USD", []string{"EUR", "GBP"})
+	if err != nil {
+		t.Fatalf("ConvertMany() error = %v", err)
+	}
+	want := map[string]float64{"EUR": 1, "GBP": 2}
+	if len(got) != len(want) || got["EUR"] != want["EUR"] || got["GBP"] != want["GBP"] {
+		t.Errorf("ConvertMany() = %v, want %v", got, want)
+	}
+}
+
+func TestMulti_ConvertMany_AllFail_AttributesEngine(t *testing.T) {
+	m := NewMulti([]Exchanger{
+		stubBatchEngine{stubEngine: stubEngine{name: "a"}, err: errors.New("boom a")},
+		stubBatchEngine{stubEngine: stubEngine{name: "b"}, err: errors.New("boom b")},
+	})
+	_, err := m.ConvertMany("USD", []string{"EUR"})
+	if err == nil {
+		t.Fatalf("ConvertMany() must fail, but it doesn't")
+	}
+	if !strings.Contains(err.Error(), "a: boom a") || !strings.Contains(err.Error(), "b: boom b") {
+		t.Errorf("ConvertMany() error = %q, want it to mention both failing engines by name", err)
+	}
+}
+
+func TestMulti_ConvertMany_NoBatchSupport(t *testing.T) {
+	m := NewMulti([]Exchanger{stubEngine{"a", 1, nil}})
+	if _, err := m.ConvertMany("USD", []string{"EUR"}); err == nil {
+		t.Errorf("ConvertMany() must fail, but it doesn't")
+	}
+}
+
+func TestMulti_ConvertMany_ExplicitByName(t *testing.T) {
+	m := NewMulti([]Exchanger{
+		stubBatchEngine{stubEngine: stubEngine{name: "a"}, rates: map[string]float64{"EUR": 1}},
+		stubBatchEngine{stubEngine: stubEngine{name: "b"}, rates: map[string]float64{"EUR": 2}},
+	}, WithExplicitName("b"))
+	got, err := m.ConvertMany("USD", []string{"EUR"})
+	if err != nil {
+		t.Fatalf("ConvertMany() error = %v", err)
+	}
+	if got["EUR"] != 2 {
+		t.Errorf("ConvertMany() = %v, want EUR=2", got)
+	}
+}
+
+type stubHistoricalEngine struct {
+	stubEngine
+	rate   float64
+	series map[time.Time]float64
+	err    error
+}
+
+func (s stubHistoricalEngine) ConvertAt(date time.Time, from, to string) (float64, error) {
+	return s.rate, s.err
+}
+
+func (s stubHistoricalEngine) ConvertAtContext(ctx context.Context, date time.Time, from, to string) (float64, error) {
+	return s.rate, s.err
+}
+
+func (s stubHistoricalEngine) TimeSeries(start, end time.Time, from, to string) (map[time.Time]float64, error) {
+	return s.series, s.err
+}
+
+func (s stubHistoricalEngine) TimeSeriesContext(ctx context.Context, start, end time.Time, from, to string) (map[time.Time]float64, error) {
+	return s.series, s.err
+}
+
+func TestMulti_ConvertAt_FirstSuccess(t *testing.T) {
+	errBoom := errors.New("boom")
+	m := NewMulti([]Exchanger{
+		stubHistoricalEngine{stubEngine: stubEngine{name: "a"}, err: errBoom},
+		stubHistoricalEngine{stubEngine: stubEngine{name: "b"}, rate: 2},
+	})
+	got, err := m.ConvertAt(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "USD", "AUD")
+	if err != nil {
+		t.Fatalf("ConvertAt() error = %v", err)
+	}
+	if got != 2 {
+		t.Errorf("ConvertAt() = %v, want 2", got)
+	}
+}
+
+func TestMulti_ConvertAt_SkipsNonHistorical(t *testing.T) {
+	m := NewMulti([]Exchanger{
+		stubEngine{"a", 1, nil},
+		stubHistoricalEngine{stubEngine: stubEngine{name: "b"}, rate: 3},
 	})
+	got, err := m.ConvertAt(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "USD", "AUD")
+	if err != nil {
+		t.Fatalf("ConvertAt() error = %v", err)
+	}
+	if got != 3 {
+		t.Errorf("ConvertAt() = %v, want 3", got)
+	}
+}
+
+func TestMulti_ConvertAt_NoHistoricalSupport(t *testing.T) {
+	m := NewMulti([]Exchanger{stubEngine{"a", 1, nil}})
+	if _, err := m.ConvertAt(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "USD", "AUD"); err == nil {
+		t.Errorf("ConvertAt() must fail, but it doesn't")
+	}
+}
+
+func TestMulti_ConvertAt_ExplicitByName(t *testing.T) {
+	m := NewMulti([]Exchanger{
+		stubHistoricalEngine{stubEngine: stubEngine{name: "a"}, rate: 1},
+		stubHistoricalEngine{stubEngine: stubEngine{name: "b"}, rate: 2},
+	}, WithExplicitName("b"))
+	got, err := m.ConvertAt(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "USD", "AUD")
+	if err != nil {
+		t.Fatalf("ConvertAt() error = %v", err)
+	}
+	if got != 2 {
+		t.Errorf("ConvertAt() = %v, want 2", got)
+	}
+}
+
+func TestMulti_ConvertAt_ExplicitByName_NotHistorical(t *testing.T) {
+	m := NewMulti([]Exchanger{
+		stubEngine{"a", 1, nil},
+	}, WithExplicitName("a"))
+	if _, err := m.ConvertAt(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "USD", "AUD"); err == nil {
+		t.Errorf("ConvertAt() must fail, but it doesn't")
+	}
+}
+
+func TestMulti_ConvertAt_ExplicitByName_Unknown(t *testing.T) {
+	m := NewMulti([]Exchanger{
+		stubHistoricalEngine{stubEngine: stubEngine{name: "a"}, rate: 1},
+	}, WithExplicitName("nope"))
+	if _, err := m.ConvertAt(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "USD", "AUD"); err == nil {
+		t.Errorf("ConvertAt() must fail, but it doesn't")
+	}
+}
+
+func TestMulti_TimeSeries_FirstSuccess(t *testing.T) {
+	want := map[time.Time]float64{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC): 1.5}
+	errBoom := errors.New("boom")
+	m := NewMulti([]Exchanger{
+		stubHistoricalEngine{stubEngine: stubEngine{name: "a"}, err: errBoom},
+		stubHistoricalEngine{stubEngine: stubEngine{name: "b"}, series: want},
+	})
+	got, err := m.TimeSeries(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "USD", "AUD")
+	if err != nil {
+		t.Fatalf("TimeSeries() error = %v", err)
+	}
+	if len(got) != 1 || got[time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)] != 1.5 {
+		t.Errorf("TimeSeries() = %v, want %v", got, want)
+	}
+}
+
+func TestMulti_TimeSeries_AllFail(t *testing.T) {
+	m := NewMulti([]Exchanger{
+		stubHistoricalEngine{stubEngine: stubEngine{name: "a"}, err: errors.New("boom a")},
+		stubHistoricalEngine{stubEngine: stubEngine{name: "b"}, err: errors.New("boom b")},
+	})
+	if _, err := m.TimeSeries(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), "USD", "AUD"); err == nil {
+		t.Errorf("TimeSeries() must fail, but it doesn't")
+	}
+}
+
+func TestMulti_Name(t *testing.T) {
+	m := NewMulti([]Exchanger{stubEngine{"a", 0, nil}, stubEngine{"b", 0, nil}})
+	if got, want := m.Name(), "multi:a:b"; got != want {
+		t.Errorf("Name() = %v, want %v", got, want)
+	}
+
+	m = NewMulti([]Exchanger{stubEngine{"a", 0, nil}}, WithExplicitName("a"))
+	if got, want := m.Name(), "a"; got != want {
+		t.Errorf("Name() = %v, want %v", got, want)
+	}
 }
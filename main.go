@@ -1,20 +1,30 @@
 // fiatconv implements CLI tool to convert between currencies.
 //
-// Uses https://exchangeratesapi.io/ service to fetch rates. Caches results
-// locally for some time to lower the load on the service as suggested.
+// Fetches rates from a pluggable set of providers (see exchange/engines),
+// falling back to the next one if the current one is down or rate-limited.
+// Caches results locally for some time to lower the load on the services as
+// suggested.
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/gob"
 	"errors"
 	"fiatconv/cache"
 	"fiatconv/exchange"
+	"fiatconv/exchange/engines/exchangeratehost"
+	"fiatconv/exchange/engines/exchangeratesapi"
+	"fiatconv/exchange/engines/frankfurter"
+	"flag"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
 	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -26,6 +36,13 @@ const (
 	fiatNameLength = 3
 	httpTimeout    = 10 * time.Second
 	cacheLifetime  = time.Hour
+	dateLayout     = "2006-01-02"
+
+	// neverExpires marks cache entries for historical data, which never
+	// changes once the day in question is in the past.
+	neverExpires = math.MaxInt64
+
+	providerEnv = "FIATCONV_PROVIDER"
 )
 
 var appFS = afero.NewOsFs()
@@ -33,7 +50,7 @@ var appFS = afero.NewOsFs()
 type request struct {
 	amount float64
 	from   string
-	to     string
+	to     []string
 }
 
 func parseArguments(args []string) (request, error) {
@@ -48,51 +65,102 @@ func parseArguments(args []string) (request, error) {
 	if len(args[2]) != fiatNameLength {
 		return request{}, fmt.Errorf("Invalid fiat: %s", args[2])
 	}
-	if len(args[3]) != fiatNameLength {
-		return request{}, fmt.Errorf("Invalid fiat: %s", args[3])
+	to := strings.Split(args[3], ",")
+	for i, t := range to {
+		to[i] = strings.ToUpper(t)
+		if len(to[i]) != fiatNameLength {
+			return request{}, fmt.Errorf("Invalid fiat: %s", args[3])
+		}
 	}
-	return request{amount, strings.ToUpper(args[2]), strings.ToUpper(args[3])}, nil
+	return request{amount, strings.ToUpper(args[2]), to}, nil
 }
 
 func printUsage(w io.Writer) {
-	fmt.Fprintln(w, `Usage: fiatconv <amount> <from_fiat> <to_fiat>
+	fmt.Fprintln(w, `Usage: fiatconv [options] <amount> <from_fiat> <to_fiat>[,<to_fiat>...]
 
 This utility converts "amount" of money in "from_fiat" currency to amount in
-"to_fiat" currency. Both currencies are given as ISO 4217 code (eg. USD).`)
+"to_fiat" currency. Both currencies are given as ISO 4217 code (eg. USD).
+"to_fiat" may be a comma-separated list (eg. EUR,GBP,JPY) to convert to
+several currencies at once, printing one line per target in order.
+
+By default providers are tried in order, falling back to the next one on
+failure. --provider (or the FIATCONV_PROVIDER environment variable) pins the
+conversion to a single named provider instead. --timeout bounds how long a
+single run may take; Ctrl-C aborts early.
+
+--at=YYYY-MM-DD looks up the rate as it was on that date instead of the
+current one. --from-date=YYYY-MM-DD and --to-date=YYYY-MM-DD (used together)
+print the rate for every day in that range, as CSV of date,rate,amount.
+Both only support a single "to_fiat".
+
+fiatconv serve [--listen=:8080] runs an HTTP daemon instead, exposing
+GET /convert?amount=&from=&to=, GET /rates?base=&symbols=EUR,GBP,JPY and
+GET /healthz over the same provider chain and cache.`)
 }
 
 type appContext struct {
 	args           []string
 	stdout, stderr io.Writer
-	convert        func(from, to string) (float64, error)
+	convert        exchange.Exchanger
 	cachePath      string
+	at             string
+	fromDate       string
+	toDate         string
 }
 
 var timeNowFn = time.Now
 
-func loadCache(path string, cutoff int64) *cache.Cache {
+// cacheKey identifies a cached rate: Date is empty for the live rate,
+// a single YYYY-MM-DD for ConvertAt, or "start..end" for a TimeSeries.
+type cacheKey struct {
+	From, To, Engine, Date string
+}
+
+func loadCache(path string) *cache.Cache {
+	c := cache.New()
 	f, err := appFS.Open(path)
 	if err != nil {
-		return cache.Load(bytes.NewBuffer(nil), cutoff)
+		return c
 	}
 	defer f.Close()
 
-	return cache.Load(f, cutoff)
+	cache.NewGobStore(c).Load(f)
+	return c
 }
 
+// saveCache writes c to path atomically: it's written to a uniquely named
+// temporary file first, fsynced, then renamed into place, so neither a
+// concurrent fiatconv run reading the cache nor another writer (eg. a
+// sibling CLI invocation, or another goroutine in the daemon) ever observes
+// a partial or clobbered write.
 func saveCache(c *cache.Cache, path string) {
-	f, err := appFS.Create(path)
+	f, err := afero.TempFile(appFS, filepath.Dir(path), filepath.Base(path)+".tmp-*")
 	if err != nil {
 		return
 	}
-	defer f.Close()
+	tmpPath := f.Name()
+	defer appFS.Remove(tmpPath)
 
-	if err := c.Save(f); err != nil {
+	if err := cache.NewGobStore(c).Save(f); err != nil {
+		f.Close()
+		fmt.Fprintf(os.Stderr, "failed to save to cache: %v\n", err)
+		return
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		fmt.Fprintf(os.Stderr, "failed to save to cache: %v\n", err)
+		return
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to save to cache: %v\n", err)
+		return
+	}
+	if err := appFS.Rename(tmpPath, path); err != nil {
 		fmt.Fprintf(os.Stderr, "failed to save to cache: %v\n", err)
 	}
 }
 
-func (app appContext) fiatConv() int {
+func (app appContext) fiatConv(ctx context.Context) int {
 	req, err := parseArguments(app.args)
 	if err != nil {
 		fmt.Fprintf(app.stderr, "%v\n\n", err)
@@ -100,30 +168,107 @@ func (app appContext) fiatConv() int {
 		return 1
 	}
 
-	type key struct {
-		From, To string
-	}
-	gob.Register(key{})
+	gob.Register(cacheKey{})
+	gob.Register(map[time.Time]float64{})
 
 	now := timeNowFn()
-	c := loadCache(app.cachePath, now.Add(-cacheLifetime).Unix())
-	var rate float64
-	k := key{req.from, req.to}
-	v, ok := c.Get(k)
-	if ok {
-		if f, ok := v.(float64); ok {
-			rate = f
+	c := loadCache(app.cachePath)
+
+	switch {
+	case app.fromDate != "" || app.toDate != "":
+		return app.timeSeriesConv(ctx, c, req)
+	case app.at != "":
+		return app.convertAtConv(ctx, c, req)
+	default:
+		return app.liveConv(ctx, c, now, req)
+	}
+}
+
+// fetchRates fetches rates for every symbol in to, using a single batched
+// call when conv supports it and there's more than one symbol, falling back
+// to one ConvertContext per symbol otherwise.
+func fetchRates(ctx context.Context, conv exchange.Exchanger, from string, to []string) (map[string]float64, error) {
+	if len(to) > 1 {
+		if b, ok := conv.(exchange.BatchExchanger); ok {
+			return b.ConvertManyContext(ctx, from, to)
+		}
+	}
+	rates := make(map[string]float64, len(to))
+	for _, t := range to {
+		rate, err := conv.ConvertContext(ctx, from, t)
+		if err != nil {
+			return nil, err
+		}
+		rates[t] = rate
+	}
+	return rates, nil
+}
+
+func (app appContext) liveConv(ctx context.Context, c *cache.Cache, now time.Time, req request) int {
+	rates := make(map[string]float64, len(req.to))
+	var missing []string
+	for _, to := range req.to {
+		k := cacheKey{req.from, to, app.convert.Name(), ""}
+		if v, found := c.Get(k, now.Unix()); found {
+			if rate, ok := v.(float64); ok {
+				rates[to] = rate
+				continue
+			}
 		}
+		missing = append(missing, to)
 	}
 
-	if rate == 0 {
-		// cache miss
-		rate, err = app.convert(req.from, req.to)
+	if len(missing) > 0 {
+		fetched, err := fetchRates(ctx, app.convert, req.from, missing)
 		if err != nil {
 			fmt.Fprintln(app.stderr, err)
 			return 1
 		}
-		c.Set(k, rate, now.Unix())
+		for to, rate := range fetched {
+			rates[to] = rate
+			c.Set(cacheKey{req.from, to, app.convert.Name(), ""}, rate, now.Add(cacheLifetime).Unix())
+		}
+		saveCache(c, app.cachePath)
+	}
+
+	for _, to := range req.to {
+		fmt.Fprintf(app.stdout, "%.2f\n", rates[to]*req.amount)
+	}
+	return 0
+}
+
+func (app appContext) convertAtConv(ctx context.Context, c *cache.Cache, req request) int {
+	if len(req.to) > 1 {
+		fmt.Fprintln(app.stderr, "--at does not support multiple target currencies")
+		return 1
+	}
+	to := req.to[0]
+
+	date, err := time.Parse(dateLayout, app.at)
+	if err != nil {
+		fmt.Fprintf(app.stderr, "Invalid --at: %v\n\n", err)
+		printUsage(app.stderr)
+		return 1
+	}
+
+	k := cacheKey{req.from, to, app.convert.Name(), date.Format(dateLayout)}
+	rate, ok := 0.0, false
+	if v, found := c.Get(k, timeNowFn().Unix()); found {
+		rate, ok = v.(float64)
+	}
+
+	if !ok {
+		hx, supported := app.convert.(exchange.HistoricalExchanger)
+		if !supported {
+			fmt.Fprintln(app.stderr, "selected provider does not support historical queries")
+			return 1
+		}
+		rate, err = hx.ConvertAtContext(ctx, date, req.from, to)
+		if err != nil {
+			fmt.Fprintln(app.stderr, err)
+			return 1
+		}
+		c.Set(k, rate, neverExpires)
 		saveCache(c, app.cachePath)
 	}
 
@@ -131,8 +276,96 @@ func (app appContext) fiatConv() int {
 	return 0
 }
 
+func (app appContext) timeSeriesConv(ctx context.Context, c *cache.Cache, req request) int {
+	if len(req.to) > 1 {
+		fmt.Fprintln(app.stderr, "--from-date/--to-date do not support multiple target currencies")
+		return 1
+	}
+	to := req.to[0]
+
+	if app.fromDate == "" || app.toDate == "" {
+		fmt.Fprintln(app.stderr, "--from-date and --to-date must be given together")
+		printUsage(app.stderr)
+		return 1
+	}
+	start, err := time.Parse(dateLayout, app.fromDate)
+	if err != nil {
+		fmt.Fprintf(app.stderr, "Invalid --from-date: %v\n\n", err)
+		printUsage(app.stderr)
+		return 1
+	}
+	end, err := time.Parse(dateLayout, app.toDate)
+	if err != nil {
+		fmt.Fprintf(app.stderr, "Invalid --to-date: %v\n\n", err)
+		printUsage(app.stderr)
+		return 1
+	}
+
+	k := cacheKey{req.from, to, app.convert.Name(), start.Format(dateLayout) + ".." + end.Format(dateLayout)}
+	var series map[time.Time]float64
+	ok := false
+	if v, found := c.Get(k, timeNowFn().Unix()); found {
+		series, ok = v.(map[time.Time]float64)
+	}
+
+	if !ok {
+		hx, supported := app.convert.(exchange.HistoricalExchanger)
+		if !supported {
+			fmt.Fprintln(app.stderr, "selected provider does not support historical queries")
+			return 1
+		}
+		series, err = hx.TimeSeriesContext(ctx, start, end, req.from, to)
+		if err != nil {
+			fmt.Fprintln(app.stderr, err)
+			return 1
+		}
+		c.Set(k, series, neverExpires)
+		saveCache(c, app.cachePath)
+	}
+
+	dates := make([]time.Time, 0, len(series))
+	for d := range series {
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	for _, d := range dates {
+		rate := series[d]
+		fmt.Fprintf(app.stdout, "%s,%.6f,%.2f\n", d.Format(dateLayout), rate, rate*req.amount)
+	}
+	return 0
+}
+
+// newExchanger builds the default fallback chain of providers, or, if
+// provider is non-empty, an Exchanger pinned to that single named provider.
+func newExchanger(client *http.Client, provider string) exchange.Exchanger {
+	engines := []exchange.Exchanger{
+		exchangeratesapi.New(exchangeratesapi.WithClient(client)),
+		frankfurter.New(frankfurter.WithClient(client)),
+		exchangeratehost.New(exchangeratehost.WithClient(client)),
+	}
+
+	var opts []exchange.Option
+	if provider != "" {
+		opts = append(opts, exchange.WithExplicitName(provider))
+	}
+	return exchange.NewMulti(engines, opts...)
+}
+
 func main() {
-	api := exchange.New(exchange.WithClient(&http.Client{Timeout: httpTimeout}))
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		os.Exit(runServe(os.Args[2:]))
+	}
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	provider := fs.String("provider", os.Getenv(providerEnv), "name of the single provider to use instead of the default fallback chain")
+	timeout := fs.Duration("timeout", httpTimeout, "maximum time to spend fetching a rate")
+	at := fs.String("at", "", "look up the rate as of this date (YYYY-MM-DD) instead of the current one")
+	fromDate := fs.String("from-date", "", "start of a --to-date range to print as a time series (YYYY-MM-DD)")
+	toDate := fs.String("to-date", "", "end of a --from-date range to print as a time series (YYYY-MM-DD)")
+	fs.Usage = func() { printUsage(os.Stderr) }
+	fs.Parse(os.Args[1:])
+
+	api := newExchanger(&http.Client{}, *provider)
 
 	var cachePath string
 	if p, err := os.UserCacheDir(); err != nil {
@@ -142,13 +375,22 @@ func main() {
 	}
 
 	app := appContext{
-		os.Args,
+		append([]string{os.Args[0]}, fs.Args()...),
 		os.Stdout,
 		os.Stderr,
-		api.Convert,
+		api,
 		cachePath,
+		*at,
+		*fromDate,
+		*toDate,
 	}
-	if code := app.fiatConv(); code != 0 {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	if code := app.fiatConv(ctx); code != 0 {
 		os.Exit(code)
 	}
 }
@@ -2,9 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/gob"
 	"errors"
+	"fiatconv/cache"
+	"fiatconv/exchange"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
 )
@@ -19,12 +24,14 @@ func Test_parseArguments(t *testing.T) {
 		{"no arguments", []string{"prog"}, request{}, true},
 		{"only amount", []string{"prog", "1"}, request{}, true},
 		{"no target", []string{"prog", "1", "USD"}, request{}, true},
-		{"all given", []string{"prog", "1", "USD", "AUD"}, request{1, "USD", "AUD"}, false},
+		{"all given", []string{"prog", "1", "USD", "AUD"}, request{1, "USD", []string{"AUD"}}, false},
 		{"wrong amount", []string{"prog", "a1", "USD", "AUD"}, request{}, true},
-		{"extra ignored", []string{"prog", "1", "USD", "AUD", "blah", "blah"}, request{1, "USD", "AUD"}, false},
-		{"case insensitive", []string{"prog", "1", "UsD", "aud"}, request{1, "USD", "AUD"}, false},
+		{"extra ignored", []string{"prog", "1", "USD", "AUD", "blah", "blah"}, request{1, "USD", []string{"AUD"}}, false},
+		{"case insensitive", []string{"prog", "1", "UsD", "aud"}, request{1, "USD", []string{"AUD"}}, false},
 		{"wrong source", []string{"prog", "1", "not_valid", "AUD"}, request{}, true},
 		{"wrong dest", []string{"prog", "1", "USD", "not_valid"}, request{}, true},
+		{"multiple targets", []string{"prog", "1", "USD", "AUD,GBP"}, request{1, "USD", []string{"AUD", "GBP"}}, false},
+		{"one invalid target in list", []string{"prog", "1", "USD", "AUD,not_valid"}, request{}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -40,16 +47,68 @@ func Test_parseArguments(t *testing.T) {
 	}
 }
 
+// stubExchanger is a minimal exchange.Exchanger for testing appContext.
+type stubExchanger struct {
+	convert func(from, to string) (float64, error)
+}
+
+func (s *stubExchanger) Name() string { return "stub" }
+func (s *stubExchanger) Convert(from, to string) (float64, error) {
+	return s.convert(from, to)
+}
+func (s *stubExchanger) ConvertContext(ctx context.Context, from, to string) (float64, error) {
+	return s.convert(from, to)
+}
+
+// stubHistoricalExchanger is a minimal exchange.HistoricalExchanger for
+// testing convertAtConv/timeSeriesConv.
+type stubHistoricalExchanger struct {
+	stubExchanger
+	convertAt  func(date time.Time, from, to string) (float64, error)
+	timeSeries func(start, end time.Time, from, to string) (map[time.Time]float64, error)
+}
+
+func (s *stubHistoricalExchanger) ConvertAt(date time.Time, from, to string) (float64, error) {
+	return s.convertAt(date, from, to)
+}
+func (s *stubHistoricalExchanger) ConvertAtContext(ctx context.Context, date time.Time, from, to string) (float64, error) {
+	return s.convertAt(date, from, to)
+}
+func (s *stubHistoricalExchanger) TimeSeries(start, end time.Time, from, to string) (map[time.Time]float64, error) {
+	return s.timeSeries(start, end, from, to)
+}
+func (s *stubHistoricalExchanger) TimeSeriesContext(ctx context.Context, start, end time.Time, from, to string) (map[time.Time]float64, error) {
+	return s.timeSeries(start, end, from, to)
+}
+
+var _ exchange.HistoricalExchanger = (*stubHistoricalExchanger)(nil)
+
+// stubBatchExchanger is a minimal exchange.BatchExchanger for testing the
+// fetchRates batch branch.
+type stubBatchExchanger struct {
+	stubExchanger
+	convertMany func(from string, to []string) (map[string]float64, error)
+}
+
+func (s *stubBatchExchanger) ConvertMany(from string, to []string) (map[string]float64, error) {
+	return s.convertMany(from, to)
+}
+func (s *stubBatchExchanger) ConvertManyContext(ctx context.Context, from string, to []string) (map[string]float64, error) {
+	return s.convertMany(from, to)
+}
+
+var _ exchange.BatchExchanger = (*stubBatchExchanger)(nil)
+
 func Test_fiatConv(t *testing.T) {
 	oldFS := appFS
 	defer func() { appFS = oldFS }()
 	appFS = afero.NewMemMapFs()
 	const cachePath = "/tmp/cache"
 	converterCalled := false
-	converter := func(from string, to string) (float64, error) {
+	converter := &stubExchanger{convert: func(from string, to string) (float64, error) {
 		converterCalled = true
 		return 2, nil
-	}
+	}}
 	stdout := bytes.NewBuffer(nil)
 	stderr := bytes.NewBuffer(nil)
 	app := appContext{
@@ -61,7 +120,7 @@ func Test_fiatConv(t *testing.T) {
 	}
 
 	t.Run("no arguments", func(t *testing.T) {
-		if code := app.fiatConv(); code == 0 {
+		if code := app.fiatConv(context.Background()); code == 0 {
 			t.Errorf("unexpected success")
 		}
 		if len(stdout.Bytes()) != 0 {
@@ -79,7 +138,7 @@ func Test_fiatConv(t *testing.T) {
 		stdout.Reset()
 		stderr.Reset()
 		app.args = []string{"prog", "5", "USD", "AUD"}
-		if code := app.fiatConv(); code != 0 {
+		if code := app.fiatConv(context.Background()); code != 0 {
 			t.Errorf("fiatConv() = %v, want 0", code)
 		}
 		if got := stdout.String(); got != "10.00\n" {
@@ -98,7 +157,7 @@ func Test_fiatConv(t *testing.T) {
 		stdout.Reset()
 		stderr.Reset()
 		app.args = []string{"prog", "5", "USD", "AUD"}
-		if code := app.fiatConv(); code != 0 {
+		if code := app.fiatConv(context.Background()); code != 0 {
 			t.Errorf("fiatConv() = %v, want 0", code)
 		}
 		if got := stdout.String(); got != "10.00\n" {
@@ -112,16 +171,42 @@ func Test_fiatConv(t *testing.T) {
 		}
 	})
 
+	t.Run("multiple targets", func(t *testing.T) {
+		converterCalled = false
+		stdout.Reset()
+		stderr.Reset()
+		app.convert = &stubExchanger{convert: func(from string, to string) (float64, error) {
+			converterCalled = true
+			if to == "GBP" {
+				return 3, nil
+			}
+			return 2, nil
+		}}
+		app.args = []string{"prog", "5", "USD", "AUD,GBP"}
+		if code := app.fiatConv(context.Background()); code != 0 {
+			t.Errorf("fiatConv() = %v, want 0", code)
+		}
+		if got := stdout.String(); got != "10.00\n15.00\n" {
+			t.Errorf("wrong stdout: %s", got)
+		}
+		if len(stderr.Bytes()) != 0 {
+			t.Errorf("unexpected stderr: %s", stderr.String())
+		}
+		if !converterCalled {
+			t.Errorf("expected API call to be made")
+		}
+	})
+
 	t.Run("API failure is reported", func(t *testing.T) {
 		converterCalled = false
 		stdout.Reset()
 		stderr.Reset()
-		app.args = []string{"prog", "5", "USD", "GBP"}
-		app.convert = func(from string, to string) (float64, error) {
+		app.args = []string{"prog", "5", "USD", "JPY"}
+		app.convert = &stubExchanger{convert: func(from string, to string) (float64, error) {
 			converterCalled = true
 			return 0, errors.New("simulated")
-		}
-		if code := app.fiatConv(); code == 0 {
+		}}
+		if code := app.fiatConv(context.Background()); code == 0 {
 			t.Errorf("fiatConv() = 0, want 1")
 		}
 		if got := stdout.String(); len(got) != 0 {
@@ -135,3 +220,160 @@ func Test_fiatConv(t *testing.T) {
 		}
 	})
 }
+
+func Test_convertAtConv(t *testing.T) {
+	oldFS := appFS
+	defer func() { appFS = oldFS }()
+	appFS = afero.NewMemMapFs()
+
+	hx := &stubHistoricalExchanger{
+		convertAt: func(date time.Time, from, to string) (float64, error) { return 2, nil },
+	}
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	app := appContext{
+		args:      []string{"prog", "5", "USD", "AUD"},
+		stdout:    stdout,
+		stderr:    stderr,
+		convert:   hx,
+		cachePath: "/tmp/cache",
+		at:        "2020-01-01",
+	}
+
+	if code := app.fiatConv(context.Background()); code != 0 {
+		t.Errorf("fiatConv() = %v, want 0", code)
+	}
+	if got := stdout.String(); got != "10.00\n" {
+		t.Errorf("wrong stdout: %s", got)
+	}
+
+	t.Run("invalid date", func(t *testing.T) {
+		stdout.Reset()
+		stderr.Reset()
+		app.at = "not-a-date"
+		if code := app.fiatConv(context.Background()); code == 0 {
+			t.Errorf("fiatConv() = 0, want failure")
+		}
+		app.at = "2020-01-01"
+	})
+
+	t.Run("multiple targets rejected", func(t *testing.T) {
+		stdout.Reset()
+		stderr.Reset()
+		app.args = []string{"prog", "5", "USD", "AUD,GBP"}
+		if code := app.fiatConv(context.Background()); code == 0 {
+			t.Errorf("fiatConv() = 0, want failure")
+		}
+		app.args = []string{"prog", "5", "USD", "AUD"}
+	})
+
+	t.Run("provider without historical support", func(t *testing.T) {
+		stdout.Reset()
+		stderr.Reset()
+		app.convert = &stubExchanger{convert: func(from, to string) (float64, error) { return 1, nil }}
+		app.cachePath = "/tmp/cache-no-historical"
+		if code := app.fiatConv(context.Background()); code == 0 {
+			t.Errorf("fiatConv() = 0, want failure")
+		}
+	})
+}
+
+func Test_timeSeriesConv(t *testing.T) {
+	oldFS := appFS
+	defer func() { appFS = oldFS }()
+	appFS = afero.NewMemMapFs()
+
+	hx := &stubHistoricalExchanger{
+		timeSeries: func(start, end time.Time, from, to string) (map[time.Time]float64, error) {
+			return map[time.Time]float64{
+				time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC): 2,
+				time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC): 3,
+			}, nil
+		},
+	}
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	app := appContext{
+		args:      []string{"prog", "5", "USD", "AUD"},
+		stdout:    stdout,
+		stderr:    stderr,
+		convert:   hx,
+		cachePath: "/tmp/cache",
+		fromDate:  "2020-01-01",
+		toDate:    "2020-01-02",
+	}
+
+	if code := app.fiatConv(context.Background()); code != 0 {
+		t.Errorf("fiatConv() = %v, want 0", code)
+	}
+	want := "2020-01-01,2.000000,10.00\n2020-01-02,3.000000,15.00\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("wrong stdout: %s, want %s", got, want)
+	}
+
+	t.Run("one of from-date/to-date missing", func(t *testing.T) {
+		stdout.Reset()
+		stderr.Reset()
+		app.toDate = ""
+		if code := app.fiatConv(context.Background()); code == 0 {
+			t.Errorf("fiatConv() = 0, want failure")
+		}
+		app.toDate = "2020-01-02"
+	})
+
+	t.Run("invalid from-date", func(t *testing.T) {
+		stdout.Reset()
+		stderr.Reset()
+		app.fromDate = "not-a-date"
+		if code := app.fiatConv(context.Background()); code == 0 {
+			t.Errorf("fiatConv() = 0, want failure")
+		}
+		app.fromDate = "2020-01-01"
+	})
+}
+
+func Test_fiatConv_BatchFetchesOnlyMissingSymbols(t *testing.T) {
+	oldFS := appFS
+	defer func() { appFS = oldFS }()
+	appFS = afero.NewMemMapFs()
+	const cachePath = "/tmp/cache"
+
+	gob.Register(cacheKey{})
+	c := cache.New()
+	c.Set(cacheKey{"USD", "AUD", "stub", ""}, 2.0, timeNowFn().Add(cacheLifetime).Unix())
+	f, err := appFS.Create(cachePath)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := cache.NewGobStore(c).Save(f); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	f.Close()
+
+	var batchedTo []string
+	batch := &stubBatchExchanger{
+		convertMany: func(from string, to []string) (map[string]float64, error) {
+			batchedTo = to
+			return map[string]float64{"GBP": 3, "JPY": 4}, nil
+		},
+	}
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	app := appContext{
+		args:      []string{"prog", "5", "USD", "AUD,GBP,JPY"},
+		stdout:    stdout,
+		stderr:    stderr,
+		convert:   batch,
+		cachePath: cachePath,
+	}
+
+	if code := app.fiatConv(context.Background()); code != 0 {
+		t.Errorf("fiatConv() = %v, want 0", code)
+	}
+	if got := stdout.String(); got != "10.00\n15.00\n20.00\n" {
+		t.Errorf("wrong stdout: %s", got)
+	}
+	if want := []string{"GBP", "JPY"}; !reflect.DeepEqual(batchedTo, want) {
+		t.Errorf("ConvertManyContext() called with %v, want %v", batchedTo, want)
+	}
+}
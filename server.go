@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fiatconv/cache"
+	"fiatconv/exchange"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const defaultListen = ":8080"
+
+// server holds the state shared by the daemon's HTTP handlers: the
+// provider chain and the on-disk rate cache, reused as-is from CLI mode.
+type server struct {
+	convert   exchange.Exchanger
+	cachePath string
+	c         *cache.Cache
+	timeout   time.Duration
+}
+
+type convertResponse struct {
+	Amount   float64   `json:"amount"`
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	Rate     float64   `json:"rate"`
+	Result   float64   `json:"result"`
+	Provider string    `json:"provider"`
+	Cached   bool      `json:"cached"`
+	AsOf     time.Time `json:"as_of"`
+}
+
+type ratesResponse struct {
+	Base     string             `json:"base"`
+	Rates    map[string]float64 `json:"rates"`
+	Provider string             `json:"provider"`
+	AsOf     time.Time          `json:"as_of"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func parseFiat(raw string) (string, bool) {
+	fiat := strings.ToUpper(strings.TrimSpace(raw))
+	return fiat, len(fiat) == fiatNameLength
+}
+
+// handleConvert serves GET /convert?amount=&from=&to=, reusing the same
+// cache and fallback chain as the one-shot CLI conversion.
+func (s *server) handleConvert(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	amount, err := strconv.ParseFloat(q.Get("amount"), 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid amount: %w", err))
+		return
+	}
+	from, ok := parseFiat(q.Get("from"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid fiat: %s", q.Get("from")))
+		return
+	}
+	to, ok := parseFiat(q.Get("to"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid fiat: %s", q.Get("to")))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	now := timeNowFn()
+	k := cacheKey{from, to, s.convert.Name(), ""}
+	rate, cached := 0.0, false
+	if v, found := s.c.Get(k, now.Unix()); found {
+		rate, cached = v.(float64), true
+	} else {
+		rate, err = s.convert.ConvertContext(ctx, from, to)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		s.c.Set(k, rate, now.Add(cacheLifetime).Unix())
+		saveCache(s.c, s.cachePath)
+	}
+
+	writeJSON(w, http.StatusOK, convertResponse{
+		Amount:   amount,
+		From:     from,
+		To:       to,
+		Rate:     rate,
+		Result:   rate * amount,
+		Provider: s.convert.Name(),
+		Cached:   cached,
+		AsOf:     now,
+	})
+}
+
+// handleRates serves GET /rates?base=&symbols=EUR,GBP,JPY, fetching each
+// missing symbol and filling in the rest from the cache.
+func (s *server) handleRates(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	base, ok := parseFiat(q.Get("base"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid fiat: %s", q.Get("base")))
+		return
+	}
+	symbolsParam := q.Get("symbols")
+	if symbolsParam == "" {
+		writeError(w, http.StatusBadRequest, errors.New("symbols is required"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	now := timeNowFn()
+	rates := make(map[string]float64)
+	var missing []string
+	for _, raw := range strings.Split(symbolsParam, ",") {
+		to, ok := parseFiat(raw)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid fiat: %s", raw))
+			return
+		}
+
+		if v, found := s.c.Get(cacheKey{base, to, s.convert.Name(), ""}, now.Unix()); found {
+			rates[to] = v.(float64)
+			continue
+		}
+		missing = append(missing, to)
+	}
+
+	if len(missing) > 0 {
+		fetched, err := fetchRates(ctx, s.convert, base, missing)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		for to, rate := range fetched {
+			rates[to] = rate
+			s.c.Set(cacheKey{base, to, s.convert.Name(), ""}, rate, now.Add(cacheLifetime).Unix())
+		}
+		saveCache(s.c, s.cachePath)
+	}
+
+	writeJSON(w, http.StatusOK, ratesResponse{
+		Base:     base,
+		Rates:    rates,
+		Provider: s.convert.Name(),
+		AsOf:     now,
+	})
+}
+
+// handleHealthz performs a single, bounded live conversion to confirm the
+// current provider chain is reachable.
+func (s *server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	if _, err := s.convert.ConvertContext(ctx, "USD", "USD"); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "provider": s.convert.Name()})
+}
+
+// runServe implements the "fiatconv serve" subcommand: an HTTP daemon
+// exposing /convert, /rates and /healthz over the same provider chain and
+// cache used by the CLI, shut down gracefully on SIGINT/SIGTERM.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", defaultListen, "address to listen on")
+	provider := fs.String("provider", os.Getenv(providerEnv), "name of the single provider to use instead of the default fallback chain")
+	timeout := fs.Duration("timeout", httpTimeout, "maximum time to spend fetching a rate per request")
+	fs.Parse(args)
+
+	gob.Register(cacheKey{})
+	gob.Register(map[time.Time]float64{})
+
+	var cachePath string
+	if p, err := os.UserCacheDir(); err != nil {
+		cachePath = "/dev/null"
+	} else {
+		cachePath = path.Join(p, path.Base(os.Args[0]))
+	}
+
+	s := &server{
+		convert:   newExchanger(&http.Client{}, *provider),
+		cachePath: cachePath,
+		c:         loadCache(cachePath),
+		timeout:   *timeout,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", s.handleConvert)
+	mux.HandleFunc("/rates", s.handleRates)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+
+	httpSrv := &http.Server{Addr: *listen, Handler: mux}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- httpSrv.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		if err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "serve: %v\n", err)
+			return 1
+		}
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+		defer cancel()
+		if err := httpSrv.Shutdown(shutdownCtx); err != nil {
+			fmt.Fprintf(os.Stderr, "serve: shutdown: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fiatconv/cache"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestServer(converter *stubExchanger) *server {
+	return &server{
+		convert:   converter,
+		cachePath: "/dev/null",
+		c:         cache.New(),
+		timeout:   time.Second,
+	}
+}
+
+func Test_handleConvert(t *testing.T) {
+	converterCalled := false
+	converter := &stubExchanger{convert: func(from, to string) (float64, error) {
+		converterCalled = true
+		return 2, nil
+	}}
+	s := newTestServer(converter)
+
+	req := httptest.NewRequest(http.MethodGet, "/convert?amount=5&from=usd&to=aud", nil)
+	rec := httptest.NewRecorder()
+	s.handleConvert(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %v, want 200; body: %s", rec.Code, rec.Body)
+	}
+	var got convertResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.From != "USD" || got.To != "AUD" || got.Rate != 2 || got.Result != 10 || got.Cached {
+		t.Errorf("unexpected response: %+v", got)
+	}
+	if !converterCalled {
+		t.Errorf("expected API call to be made")
+	}
+
+	converterCalled = false
+	req = httptest.NewRequest(http.MethodGet, "/convert?amount=5&from=usd&to=aud", nil)
+	rec = httptest.NewRecorder()
+	s.handleConvert(rec, req)
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !got.Cached {
+		t.Errorf("expected second call to be served from cache")
+	}
+	if converterCalled {
+		t.Errorf("unexpected API call on cached request")
+	}
+}
+
+func Test_handleConvert_InvalidParams(t *testing.T) {
+	s := newTestServer(&stubExchanger{convert: func(from, to string) (float64, error) { return 0, nil }})
+
+	req := httptest.NewRequest(http.MethodGet, "/convert?amount=nope&from=USD&to=AUD", nil)
+	rec := httptest.NewRecorder()
+	s.handleConvert(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %v, want 400", rec.Code)
+	}
+}
+
+func Test_handleRates(t *testing.T) {
+	s := newTestServer(&stubExchanger{convert: func(from, to string) (float64, error) {
+		if to == "GBP" {
+			return 0.8, nil
+		}
+		return 1.2, nil
+	}})
+
+	req := httptest.NewRequest(http.MethodGet, "/rates?base=usd&symbols=eur,gbp", nil)
+	rec := httptest.NewRecorder()
+	s.handleRates(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %v, want 200; body: %s", rec.Code, rec.Body)
+	}
+	var got ratesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := map[string]float64{"EUR": 1.2, "GBP": 0.8}
+	if got.Base != "USD" || len(got.Rates) != len(want) || got.Rates["EUR"] != want["EUR"] || got.Rates["GBP"] != want["GBP"] {
+		t.Errorf("unexpected response: %+v", got)
+	}
+}
+
+func Test_handleHealthz(t *testing.T) {
+	ok := newTestServer(&stubExchanger{convert: func(from, to string) (float64, error) { return 1, nil }})
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rec := httptest.NewRecorder()
+	ok.handleHealthz(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %v, want 200", rec.Code)
+	}
+
+	down := newTestServer(&stubExchanger{convert: func(from, to string) (float64, error) {
+		return 0, errors.New("simulated")
+	}})
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rec = httptest.NewRecorder()
+	down.handleHealthz(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %v, want 503", rec.Code)
+	}
+}